@@ -0,0 +1,197 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"math/big"
+	"os"
+	"strings"
+
+	"github.com/codesoap/atto"
+)
+
+// batchLine is a single "AMOUNT RECEIVER" pair read from a batch file.
+type batchLine struct {
+	lineNum  int
+	amount   string
+	receiver string
+}
+
+// batchBlock pairs a batchLine with the signed block built for it.
+type batchBlock struct {
+	batchLine
+	block atto.Block
+}
+
+func batchSend() error {
+	path := flag.Arg(1)
+	lines, err := parseBatchFile(path)
+	if err != nil {
+		return err
+	}
+	if len(lines) == 0 {
+		return fmt.Errorf("%s contains no send lines", path)
+	}
+
+	seed, err := getSeed()
+	if err != nil {
+		return err
+	}
+	privateKey, err := atto.NewPrivateKey(seed, settings.AccountIndex)
+	if err != nil {
+		return err
+	}
+	account, err := atto.NewAccount(privateKey)
+	if err != nil {
+		return err
+	}
+	if err := letUserVerifyBatch(lines); err != nil {
+		return err
+	}
+
+	info, err := account.FetchAccountInfo(settings.NodeURL)
+	if err != nil {
+		return err
+	}
+
+	blocks := make([]*batchBlock, len(lines))
+	for i, l := range lines {
+		block, err := info.Send(l.amount, l.receiver)
+		if err != nil {
+			return fmt.Errorf("line %d: %w", l.lineNum, err)
+		}
+		if err := block.Sign(privateKey); err != nil {
+			return fmt.Errorf("line %d: %w", l.lineNum, err)
+		}
+		blocks[i] = &batchBlock{batchLine: l, block: block}
+	}
+
+	workDone := precomputeWork(blocks)
+
+	for i, b := range blocks {
+		fmt.Fprintf(os.Stderr, "[%d/%d] sending %s XNO to %s... ", i+1, len(blocks), b.amount, b.receiver)
+		if err := <-workDone[i]; err != nil {
+			fmt.Fprintln(os.Stderr, "failed")
+			printResumeTranscript(lines[i:])
+			return fmt.Errorf("line %d: could not compute work: %w", b.lineNum, err)
+		}
+		if err := b.block.Submit(settings.NodeURL); err != nil {
+			fmt.Fprintln(os.Stderr, "failed")
+			printResumeTranscript(lines[i:])
+			return fmt.Errorf("line %d: %w", b.lineNum, err)
+		}
+		fmt.Fprintln(os.Stderr, "done")
+	}
+	fmt.Fprintf(os.Stderr, "Sent all %d transactions.\n", len(blocks))
+	return nil
+}
+
+// precomputeWork starts a bounded pool of jobsFlag workers that fetch
+// the proof of work for every block concurrently, so work for block N+1
+// is already underway while block N is submitted. It returns, for each
+// block, a channel that receives that block's FetchWork result; the
+// buffered channels are themselves the synchronization, so callers can
+// read done[i] in order without waiting on the workers directly.
+func precomputeWork(blocks []*batchBlock) []chan error {
+	done := make([]chan error, len(blocks))
+	for i := range done {
+		done[i] = make(chan error, 1)
+	}
+
+	jobs := jobsFlag
+	if jobs == 0 {
+		jobs = 1
+	}
+	sem := make(chan struct{}, jobs)
+	go func() {
+		// Take a slot before starting each worker, so work is started
+		// in block order and never more than jobs at a time.
+		for i, b := range blocks {
+			sem <- struct{}{}
+			go func(i int, b *batchBlock) {
+				defer func() { <-sem }()
+				done[i] <- b.block.FetchWork(settings.NodeURL)
+			}(i, b)
+		}
+	}()
+	return done
+}
+
+func parseBatchFile(path string) ([]batchLine, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("could not open %s: %w", path, err)
+	}
+	defer f.Close()
+
+	var lines []batchLine
+	scanner := bufio.NewScanner(f)
+	lineNum := 0
+	for scanner.Scan() {
+		lineNum++
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" {
+			continue
+		}
+		fields := strings.Fields(text)
+		if len(fields) != 2 {
+			return nil, fmt.Errorf("%s:%d: expected 'AMOUNT RECEIVER', got %q", path, lineNum, text)
+		}
+		lines = append(lines, batchLine{lineNum: lineNum, amount: fields[0], receiver: fields[1]})
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("could not read %s: %w", path, err)
+	}
+	return lines, nil
+}
+
+// letUserVerifyBatch asks for a single confirmation summarizing the
+// whole batch, unless -y was given.
+func letUserVerifyBatch(lines []batchLine) error {
+	if yFlag {
+		return nil
+	}
+	// Amounts are in XNO, as for the send subcommand, so they may have
+	// a fractional part.
+	total := new(big.Rat)
+	for _, l := range lines {
+		amount, ok := new(big.Rat).SetString(l.amount)
+		if !ok {
+			return fmt.Errorf("line %d: cannot parse '%s' as a number", l.lineNum, l.amount)
+		}
+		total.Add(total, amount)
+	}
+	totalXNO := strings.TrimRight(strings.TrimRight(total.FloatString(30), "0"), ".")
+	fmt.Fprintf(os.Stderr, "Send a total of %s XNO to %d recipients? [y/N] ", totalXNO, len(lines))
+	answer, err := stdin.ReadString('\n')
+	if answer == "" && err != nil {
+		return err
+	}
+	switch strings.TrimSpace(strings.ToLower(answer)) {
+	case "y", "yes":
+		return nil
+	default:
+		return fmt.Errorf("aborted by user")
+	}
+}
+
+// printResumeTranscript prints the "AMOUNT RECEIVER" lines that were
+// not yet submitted, so the user can retry them in a new batch file.
+func printResumeTranscript(remaining []batchLine) {
+	fmt.Fprintln(os.Stderr, "Not yet sent; retry with a batch file containing:")
+	for _, l := range remaining {
+		fmt.Fprintf(os.Stderr, "%s %s\n", l.amount, l.receiver)
+	}
+}
+
+// rawToXNOOrRaw formats a raw amount as XNO, falling back to the raw
+// string itself if it cannot be parsed (progress output should never
+// fail the batch over a formatting issue).
+func rawToXNOOrRaw(raw string) string {
+	amount, ok := big.NewInt(0).SetString(raw, 10)
+	if !ok {
+		return raw
+	}
+	return rawToXNO(amount)
+}
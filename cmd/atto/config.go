@@ -0,0 +1,198 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+)
+
+// Settings is the resolved configuration for a single invocation, built
+// up from (lowest to highest priority) hard-coded defaults, the config
+// file, environment variables and command-line flags.
+type Settings struct {
+	NodeURL               string `json:"node_url"`
+	DefaultRepresentative string `json:"default_representative"`
+	AccountIndex          uint32 `json:"account_index"`
+	SeedFile              string `json:"seed_file,omitempty"`
+}
+
+func defaultSettings() Settings {
+	return Settings{
+		NodeURL:               "https://proxy.nanos.cc/proxy",
+		DefaultRepresentative: "nano_1natrium1o3z5519ifou7xii8crpxpk8y65qmkih8e8bpsjri651oza8imdd",
+	}
+}
+
+// Profile is a single named configuration entry in the config file.
+// Fields left out or empty fall back to the next lower priority source.
+type Profile struct {
+	NodeURL               string  `json:"node_url,omitempty"`
+	DefaultRepresentative string  `json:"default_representative,omitempty"`
+	AccountIndex          *uint32 `json:"account_index,omitempty"`
+	SeedFile              string  `json:"seed_file,omitempty"`
+}
+
+// Config is the on-disk schema of the config file.
+type Config struct {
+	DefaultProfile string             `json:"default_profile"`
+	Profiles       map[string]Profile `json:"profiles"`
+}
+
+// defaultConfigPath returns $XDG_CONFIG_HOME/atto/config.json, falling
+// back to ~/.config/atto/config.json if XDG_CONFIG_HOME is unset.
+func defaultConfigPath() string {
+	dir := os.Getenv("XDG_CONFIG_HOME")
+	if dir == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return ""
+		}
+		dir = filepath.Join(home, ".config")
+	}
+	return filepath.Join(dir, "atto", "config.json")
+}
+
+func loadConfig(path string) (*Config, error) {
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("could not read %s: %w", path, err)
+	}
+	var cfg Config
+	if err := json.Unmarshal(data, &cfg); err != nil {
+		return nil, fmt.Errorf("could not parse %s: %w", path, err)
+	}
+	return &cfg, nil
+}
+
+// resolveSettings builds the effective Settings for this invocation:
+// hard-coded defaults, then the config file's selected profile, then
+// environment variables, then explicit command-line flags.
+func resolveSettings() (Settings, error) {
+	result := defaultSettings()
+
+	path := configFileFlag
+	if path == "" {
+		path = defaultConfigPath()
+	}
+	cfg, err := loadConfig(path)
+	if err != nil {
+		return result, err
+	}
+	if cfg != nil {
+		name := profileFlag
+		if name == "" {
+			name = cfg.DefaultProfile
+		}
+		if name != "" {
+			profile, ok := cfg.Profiles[name]
+			if !ok {
+				return result, fmt.Errorf("profile %q not found in %s", name, path)
+			}
+			applyProfile(&result, profile)
+		}
+	} else if profileFlag != "" {
+		return result, fmt.Errorf("-p given but no config file was found")
+	}
+
+	if v := os.Getenv("ATTO_NODE_URL"); v != "" {
+		result.NodeURL = v
+	}
+	if v := os.Getenv("ATTO_REPRESENTATIVE"); v != "" {
+		result.DefaultRepresentative = v
+	}
+	if v := os.Getenv("ATTO_ACCOUNT_INDEX"); v != "" {
+		index, err := strconv.ParseUint(v, 10, 32)
+		if err != nil {
+			return result, fmt.Errorf("invalid ATTO_ACCOUNT_INDEX %q: %w", v, err)
+		}
+		result.AccountIndex = uint32(index)
+	}
+	if v := os.Getenv("ATTO_SEED_FILE"); v != "" {
+		result.SeedFile = v
+	}
+
+	flagSet := map[string]bool{}
+	flag.Visit(func(f *flag.Flag) { flagSet[f.Name] = true })
+	if flagSet["a"] {
+		result.AccountIndex = uint32(accountIndexFlag)
+	}
+	if flagSet["k"] {
+		result.SeedFile = keyFileFlag
+	}
+
+	return result, nil
+}
+
+func applyProfile(settings *Settings, profile Profile) {
+	if profile.NodeURL != "" {
+		settings.NodeURL = profile.NodeURL
+	}
+	if profile.DefaultRepresentative != "" {
+		settings.DefaultRepresentative = profile.DefaultRepresentative
+	}
+	if profile.AccountIndex != nil {
+		settings.AccountIndex = *profile.AccountIndex
+	}
+	if profile.SeedFile != "" {
+		settings.SeedFile = profile.SeedFile
+	}
+}
+
+func configCommand() error {
+	switch flag.Arg(1) {
+	case "init":
+		return configInit()
+	case "show":
+		return configShow()
+	}
+	return fmt.Errorf("unknown config subcommand %q", flag.Arg(1))
+}
+
+func configInit() error {
+	path := configFileFlag
+	if path == "" {
+		path = defaultConfigPath()
+	}
+	if path == "" {
+		return fmt.Errorf("could not determine a config path; pass -c FILE")
+	}
+	if _, err := os.Stat(path); err == nil {
+		return fmt.Errorf("%s already exists", path)
+	}
+	defaults := defaultSettings()
+	starter := Config{
+		DefaultProfile: "main",
+		Profiles: map[string]Profile{
+			"main": {
+				NodeURL:               defaults.NodeURL,
+				DefaultRepresentative: defaults.DefaultRepresentative,
+			},
+		},
+	}
+	data, err := json.MarshalIndent(starter, "", "\t")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return fmt.Errorf("could not create %s: %w", filepath.Dir(path), err)
+	}
+	if err := os.WriteFile(path, append(data, '\n'), 0600); err != nil {
+		return fmt.Errorf("could not write %s: %w", path, err)
+	}
+	fmt.Fprintf(os.Stderr, "Wrote starter config to %s\n", path)
+	return nil
+}
+
+func configShow() error {
+	data, err := json.MarshalIndent(settings, "", "\t")
+	if err != nil {
+		return err
+	}
+	fmt.Println(string(data))
+	return nil
+}
@@ -1,10 +1,12 @@
 package main
 
 import (
+	"bufio"
 	"flag"
 	"fmt"
 	"math/big"
 	"os"
+	"strings"
 
 	"github.com/codesoap/atto"
 )
@@ -12,10 +14,23 @@ import (
 var usage = `Usage:
 	atto -v
 	atto n[ew]
-	atto [-a ACCOUNT_INDEX] a[ddress]
-	atto [-a ACCOUNT_INDEX] b[alance]
-	atto [-a ACCOUNT_INDEX] r[epresentative] REPRESENTATIVE
-	atto [-a ACCOUNT_INDEX] [-y] s[end] AMOUNT RECEIVER
+	atto [-c FILE] [-p PROFILE] [-a ACCOUNT_INDEX] [-k KEYFILE] a[ddress]
+	atto [-c FILE] [-p PROFILE] [-a ACCOUNT_INDEX] [-k KEYFILE] b[alance]
+	atto [-c FILE] [-p PROFILE] [-a ACCOUNT_INDEX] [-k KEYFILE] r[epresentative] REPRESENTATIVE
+	atto [-c FILE] [-p PROFILE] [-a ACCOUNT_INDEX] [-k KEYFILE] [-y] s[end] AMOUNT RECEIVER
+	atto [-c FILE] [-p PROFILE] [-a ACCOUNT_INDEX] [-k KEYFILE] [-y] [-j JOBS] batch FILE
+	atto [-c FILE] craft send ADDRESS ACCOUNT_INDEX AMOUNT RECEIVER
+	atto [-c FILE] craft change ADDRESS ACCOUNT_INDEX REPRESENTATIVE
+	atto [-c FILE] craft receive ADDRESS ACCOUNT_INDEX [REPRESENTATIVE]
+	atto sign
+	atto [-c FILE] broadcast
+	atto [-c FILE] watch balance ADDRESS
+	atto [-c FILE] watch pending ADDRESS
+	atto [-c FILE] [-n COUNT] watch history ADDRESS
+	atto -k KEYFILE [--allow-weak] encrypt
+	atto -k KEYFILE decrypt
+	atto [-c FILE] config init
+	atto [-c FILE] [-p PROFILE] config show
 
 If the -v flag is provided, atto will print its version number.
 
@@ -27,6 +42,10 @@ as the first line of their standard input. Showing the first address of
 a newly generated key could work like this:
 atto new | tee seed.txt | atto address
 
+If the -k flag is given, the seed is instead read from the passphrase
+encrypted KEYFILE and the passphrase is read from the terminal, so the
+plaintext seed never touches stdin, shell history or a temporary file.
+
 The send subcommand also expects manual confirmation of the transaction,
 unless the -y flag is given.
 
@@ -35,13 +54,66 @@ subcommand receives pending sends and shows the balance of an account,
 the representative subcommand changes the account's representative and
 the send subcommand sends funds to an address.
 
+The batch subcommand reads one "AMOUNT RECEIVER" pair per line from FILE
+and sends them all, fetching account info only once and updating the
+local balance and frontier after each block instead of refetching. A
+pool of -j worker goroutines (default 4) precomputes the proof of work
+for upcoming blocks while earlier blocks are being submitted, so
+throughput is not serialized on work generation. Unless -y is given, it
+asks for a single confirmation summarizing the total XNO and number of
+recipients. If a send fails partway through, the remaining "AMOUNT
+RECEIVER" lines are printed to stderr so the batch can be retried.
+
+The craft, sign and broadcast subcommands split block creation from
+signing and submission, so a cold-storage machine that never touches
+the network can hold the seed while an online machine does account
+discovery and broadcast. craft takes a public ADDRESS and ACCOUNT_INDEX
+(no seed needed) and emits an unsigned block as a versioned JSON
+envelope on stdout; receive crafts a block for the oldest pending send,
+using REPRESENTATIVE if the account still needs to be opened. sign
+reads one envelope per line from stdin, signs each with the seed's
+private key for the envelope's account_index and refuses to sign if
+that key's address does not match the envelope's account, to guard
+against substitution across the air gap. broadcast reads signed
+envelopes from stdin, fetches their proof of work and submits them.
+
+The watch subcommand observes an account from its public ADDRESS alone,
+without ever touching a seed: watch balance prints the account's
+balance, watch pending lists its pending sends and watch history prints
+its last COUNT blocks (default 10, set with -n). This is safe to run on
+untrusted machines and in scripts that only need to monitor an account.
+
+The encrypt subcommand reads a plaintext seed as the first line of its
+standard input, asks for a passphrase on the terminal and writes the
+seed, encrypted with that passphrase, to KEYFILE. Passphrases that are
+estimated to be too weak are rejected unless --allow-weak is given. The
+decrypt subcommand reverses this, asking for the passphrase and printing
+the plaintext seed to stdout; this is mostly useful for scripting around
+commands that do not yet support -k directly.
+
 ACCOUNT_INDEX is an optional parameter, which must be a number between 0
 and 4,294,967,295. It allows you to use multiple accounts derived from
 the same seed. By default the account with index 0 is chosen.
+
+Settings such as the node URL, default representative, account index
+and seed file are resolved, lowest to highest priority, from built-in
+defaults, the config file, the environment variables ATTO_NODE_URL,
+ATTO_REPRESENTATIVE, ATTO_ACCOUNT_INDEX and ATTO_SEED_FILE, and finally
+the -a and -k flags. The config file defaults to
+$XDG_CONFIG_HOME/atto/config.json (or -c FILE) and holds named profiles,
+selected with -p NAME or the file's "default_profile". The config init
+subcommand scaffolds a starter config file and config show prints the
+settings that would be used for the given flags.
 `
 
 var accountIndexFlag uint
 var yFlag bool
+var keyFileFlag string
+var allowWeakFlag bool
+var configFileFlag string
+var profileFlag string
+var jobsFlag uint
+var historyCountFlag uint
 
 func init() {
 	var vFlag bool
@@ -49,6 +121,12 @@ func init() {
 	flag.UintVar(&accountIndexFlag, "a", 0, "")
 	flag.BoolVar(&yFlag, "y", false, "")
 	flag.BoolVar(&vFlag, "v", false, "")
+	flag.StringVar(&keyFileFlag, "k", "", "")
+	flag.BoolVar(&allowWeakFlag, "allow-weak", false, "")
+	flag.StringVar(&configFileFlag, "c", "", "")
+	flag.StringVar(&profileFlag, "p", "", "")
+	flag.UintVar(&jobsFlag, "j", 4, "")
+	flag.UintVar(&historyCountFlag, "n", 10, "")
 	flag.Parse()
 	if vFlag {
 		fmt.Println("1.3.0")
@@ -59,13 +137,33 @@ func init() {
 		os.Exit(1)
 	}
 	var ok bool
-	switch flag.Arg(0)[:1] {
-	case "n", "a", "b":
+	switch flag.Arg(0) {
+	case "n", "new", "a", "address", "b", "balance", "e", "encrypt", "d", "decrypt":
 		ok = flag.NArg() == 1
-	case "r":
+	case "r", "representative":
 		ok = flag.NArg() == 2
-	case "s":
+	case "s", "send":
 		ok = flag.NArg() == 3
+	case "batch":
+		ok = flag.NArg() == 2
+	case "craft":
+		switch flag.Arg(1) {
+		case "send":
+			ok = flag.NArg() == 6
+		case "change":
+			ok = flag.NArg() == 5
+		case "receive":
+			ok = flag.NArg() == 4 || flag.NArg() == 5
+		}
+	case "sign", "broadcast":
+		ok = flag.NArg() == 1
+	case "watch":
+		switch flag.Arg(1) {
+		case "balance", "pending", "history":
+			ok = flag.NArg() == 3
+		}
+	case "c", "config":
+		ok = flag.NArg() == 2 && (flag.Arg(1) == "init" || flag.Arg(1) == "show")
 	}
 	if !ok {
 		flag.Usage()
@@ -73,19 +171,42 @@ func init() {
 	}
 }
 
+var settings Settings
+
 func main() {
 	var err error
-	switch flag.Arg(0)[:1] {
-	case "n":
+	settings, err = resolveSettings()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(2)
+	}
+	switch flag.Arg(0) {
+	case "n", "new":
 		err = printNewSeed()
-	case "a":
+	case "a", "address":
 		err = printAddress()
-	case "b":
+	case "b", "balance":
 		err = printBalance()
-	case "r":
+	case "r", "representative":
 		err = changeRepresentative()
-	case "s":
+	case "s", "send":
 		err = sendFunds()
+	case "batch":
+		err = batchSend()
+	case "craft":
+		err = craftCommand()
+	case "sign":
+		err = signBlocks()
+	case "broadcast":
+		err = broadcastBlocks()
+	case "watch":
+		err = watchCommand()
+	case "e", "encrypt":
+		err = encryptSeed()
+	case "d", "decrypt":
+		err = decryptSeed()
+	case "c", "config":
+		err = configCommand()
 	}
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
@@ -106,7 +227,7 @@ func printAddress() error {
 	if err != nil {
 		return err
 	}
-	privateKey, err := atto.NewPrivateKey(seed, uint32(accountIndexFlag))
+	privateKey, err := atto.NewPrivateKey(seed, settings.AccountIndex)
 	if err != nil {
 		return err
 	}
@@ -122,7 +243,7 @@ func printBalance() error {
 	if err != nil {
 		return err
 	}
-	privateKey, err := atto.NewPrivateKey(seed, uint32(accountIndexFlag))
+	privateKey, err := atto.NewPrivateKey(seed, settings.AccountIndex)
 	if err != nil {
 		return err
 	}
@@ -131,7 +252,7 @@ func printBalance() error {
 		return err
 	}
 	firstReceive := false // Is this the very first block of the account?
-	info, err := account.FetchAccountInfo(node)
+	info, err := account.FetchAccountInfo(settings.NodeURL)
 	if err == atto.ErrAccountNotFound {
 		// Needed for printing balance, even if nothing is pending:
 		info.Balance = "0"
@@ -140,7 +261,7 @@ func printBalance() error {
 	} else if err != nil {
 		return err
 	}
-	pendings, err := account.FetchPending(node)
+	pendings, err := account.FetchPending(settings.NodeURL)
 	if err != nil {
 		return err
 	}
@@ -154,7 +275,7 @@ func printBalance() error {
 		var block atto.Block
 		if firstReceive {
 			fmt.Fprintf(os.Stderr, "opening account... ")
-			info, block, err = account.FirstReceive(pending, defaultRepresentative)
+			info, block, err = account.FirstReceive(pending, settings.DefaultRepresentative)
 			firstReceive = false
 		} else {
 			block, err = info.Receive(pending)
@@ -165,10 +286,10 @@ func printBalance() error {
 		if err = block.Sign(privateKey); err != nil {
 			return err
 		}
-		if err = block.FetchWork(node); err != nil {
+		if err = block.FetchWork(settings.NodeURL); err != nil {
 			return err
 		}
-		if err = block.Submit(node); err != nil {
+		if err = block.Submit(settings.NodeURL); err != nil {
 			return err
 		}
 		fmt.Fprintln(os.Stderr, "done")
@@ -187,7 +308,7 @@ func changeRepresentative() error {
 	if err != nil {
 		return err
 	}
-	privateKey, err := atto.NewPrivateKey(seed, uint32(accountIndexFlag))
+	privateKey, err := atto.NewPrivateKey(seed, settings.AccountIndex)
 	if err != nil {
 		return err
 	}
@@ -195,7 +316,7 @@ func changeRepresentative() error {
 	if err != nil {
 		return err
 	}
-	info, err := account.FetchAccountInfo(node)
+	info, err := account.FetchAccountInfo(settings.NodeURL)
 	if err != nil {
 		return err
 	}
@@ -208,10 +329,10 @@ func changeRepresentative() error {
 	if err = block.Sign(privateKey); err != nil {
 		return err
 	}
-	if err = block.FetchWork(node); err != nil {
+	if err = block.FetchWork(settings.NodeURL); err != nil {
 		return err
 	}
-	if err = block.Submit(node); err != nil {
+	if err = block.Submit(settings.NodeURL); err != nil {
 		return err
 	}
 	fmt.Fprintln(os.Stderr, "done")
@@ -225,7 +346,7 @@ func sendFunds() error {
 	if err != nil {
 		return err
 	}
-	privateKey, err := atto.NewPrivateKey(seed, uint32(accountIndexFlag))
+	privateKey, err := atto.NewPrivateKey(seed, settings.AccountIndex)
 	if err != nil {
 		return err
 	}
@@ -236,7 +357,7 @@ func sendFunds() error {
 	if err = letUserVerifySend(amount, recipient); err != nil {
 		return err
 	}
-	info, err := account.FetchAccountInfo(node)
+	info, err := account.FetchAccountInfo(settings.NodeURL)
 	if err != nil {
 		return err
 	}
@@ -249,12 +370,114 @@ func sendFunds() error {
 	if err = block.Sign(privateKey); err != nil {
 		return err
 	}
-	if err = block.FetchWork(node); err != nil {
+	if err = block.FetchWork(settings.NodeURL); err != nil {
 		return err
 	}
-	if err = block.Submit(node); err != nil {
+	if err = block.Submit(settings.NodeURL); err != nil {
 		return err
 	}
 	fmt.Fprintln(os.Stderr, "done")
 	return nil
 }
+
+func encryptSeed() error {
+	if keyFileFlag == "" {
+		return fmt.Errorf("the -k flag is required for the encrypt subcommand")
+	}
+	seed, err := getSeedFromStdin()
+	if err != nil {
+		return err
+	}
+	passphrase, err := readPassphrase("New passphrase: ", "Confirm passphrase: ")
+	if err != nil {
+		return err
+	}
+	if !allowWeakFlag {
+		if err := checkPassphraseStrength(passphrase); err != nil {
+			return err
+		}
+	}
+	encoded, err := sealSeed(seed, passphrase)
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(keyFileFlag, encoded, 0600); err != nil {
+		return fmt.Errorf("could not write %s: %w", keyFileFlag, err)
+	}
+	fmt.Fprintf(os.Stderr, "Encrypted seed written to %s\n", keyFileFlag)
+	return nil
+}
+
+func decryptSeed() error {
+	if keyFileFlag == "" {
+		return fmt.Errorf("the -k flag is required for the decrypt subcommand")
+	}
+	seed, err := getSeedFromKeyFile(keyFileFlag)
+	if err != nil {
+		return err
+	}
+	fmt.Println(seed)
+	return nil
+}
+
+// getSeed reads the seed used by every seed-consuming subcommand. If a
+// seed file was given, either with -k or via the resolved settings, the
+// seed is decrypted from that file after prompting for its passphrase
+// on the terminal; otherwise the seed is read from the first line of
+// stdin, as before.
+func getSeed() (string, error) {
+	if settings.SeedFile != "" {
+		return getSeedFromKeyFile(settings.SeedFile)
+	}
+	return getSeedFromStdin()
+}
+
+// stdin is the single buffered reader used for every sequential read
+// from standard input in one invocation (seed, then passphrase, then
+// confirmations). A fresh bufio.Scanner or bufio.Reader per read would
+// each buffer ahead independently, so when stdin is a pipe the first
+// one can silently swallow input meant for the next.
+var stdin = bufio.NewReader(os.Stdin)
+
+func getSeedFromStdin() (string, error) {
+	line, err := stdin.ReadString('\n')
+	line = strings.TrimRight(line, "\r\n")
+	if line == "" && err != nil {
+		return "", fmt.Errorf("no seed found on stdin")
+	}
+	return line, nil
+}
+
+func getSeedFromKeyFile(path string) (string, error) {
+	encoded, err := os.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("could not read %s: %w", path, err)
+	}
+	passphrase, err := readPassphrase("Passphrase: ", "")
+	if err != nil {
+		return "", err
+	}
+	return openSeed(encoded, passphrase)
+}
+
+func letUserVerifySend(amount, recipient string) error {
+	if yFlag {
+		return nil
+	}
+	fmt.Fprintf(os.Stderr, "Send %s XNO to %s? [y/N] ", amount, recipient)
+	answer, err := stdin.ReadString('\n')
+	if answer == "" && err != nil {
+		return err
+	}
+	switch strings.TrimSpace(strings.ToLower(answer)) {
+	case "y", "yes":
+		return nil
+	default:
+		return fmt.Errorf("aborted by user")
+	}
+}
+
+func rawToXNO(raw *big.Int) string {
+	xno := new(big.Rat).SetFrac(raw, big.NewInt(1_000_000_000_000_000_000_000_000_000_000))
+	return strings.TrimRight(strings.TrimRight(xno.FloatString(30), "0"), ".")
+}
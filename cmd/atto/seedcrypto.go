@@ -0,0 +1,194 @@
+package main
+
+import (
+	"crypto/rand"
+	"encoding/base32"
+	"encoding/binary"
+	"fmt"
+	"math"
+	"os"
+	"strings"
+
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/chacha20poly1305"
+	"golang.org/x/term"
+
+	zxcvbn "github.com/nbutton23/zxcvbn-go"
+)
+
+// seedMagic identifies an atto encrypted seed file. The version byte
+// that follows lets the on-disk format change later without breaking
+// detection of non-atto files.
+const seedMagic = "atto-seed"
+const seedFormatVersion = 1
+
+const (
+	argon2Time    = 3
+	argon2Memory  = 64 * 1024 // KiB
+	argon2Threads = 4
+	argon2KeyLen  = chacha20poly1305.KeySize
+	saltSize      = 16
+
+	minPassphraseScore = 2
+)
+
+// kdfParamsSize is the on-disk size of the serialized Argon2id
+// parameters: time (4 bytes), memory (4 bytes), threads (1 byte) and
+// key length (4 bytes), big-endian.
+const kdfParamsSize = 4 + 4 + 1 + 4
+
+// kdfParams is the set of Argon2id tuning parameters used to derive a
+// keyfile's encryption key. These are persisted in the keyfile itself
+// rather than assumed from the current argon2* constants, so that
+// tuning those constants in a later release doesn't strand existing
+// keyfiles: openSeed always derives the key with the parameters the
+// file was actually sealed with.
+type kdfParams struct {
+	time    uint32
+	memory  uint32
+	threads uint8
+	keyLen  uint32
+}
+
+func appendKDFParams(buf []byte, p kdfParams) []byte {
+	var b [kdfParamsSize]byte
+	binary.BigEndian.PutUint32(b[0:4], p.time)
+	binary.BigEndian.PutUint32(b[4:8], p.memory)
+	b[8] = p.threads
+	binary.BigEndian.PutUint32(b[9:13], p.keyLen)
+	return append(buf, b[:]...)
+}
+
+func readKDFParams(raw []byte) kdfParams {
+	return kdfParams{
+		time:    binary.BigEndian.Uint32(raw[0:4]),
+		memory:  binary.BigEndian.Uint32(raw[4:8]),
+		threads: raw[8],
+		keyLen:  binary.BigEndian.Uint32(raw[9:13]),
+	}
+}
+
+// sealSeed encrypts seed with an Argon2id-derived key and returns the
+// base32-encoded on-disk representation: magic, version, KDF
+// parameters, salt, nonce and ciphertext.
+func sealSeed(seed, passphrase string) ([]byte, error) {
+	params := kdfParams{time: argon2Time, memory: argon2Memory, threads: argon2Threads, keyLen: argon2KeyLen}
+
+	salt := make([]byte, saltSize)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, fmt.Errorf("could not generate salt: %w", err)
+	}
+	key := argon2.IDKey([]byte(passphrase), salt, params.time, params.memory, params.threads, params.keyLen)
+	aead, err := chacha20poly1305.NewX(key)
+	if err != nil {
+		return nil, fmt.Errorf("could not initialize cipher: %w", err)
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, fmt.Errorf("could not generate nonce: %w", err)
+	}
+	ciphertext := aead.Seal(nil, nonce, []byte(seed), nil)
+
+	raw := make([]byte, 0, len(seedMagic)+1+kdfParamsSize+len(salt)+len(nonce)+len(ciphertext))
+	raw = append(raw, []byte(seedMagic)...)
+	raw = append(raw, seedFormatVersion)
+	raw = appendKDFParams(raw, params)
+	raw = append(raw, salt...)
+	raw = append(raw, nonce...)
+	raw = append(raw, ciphertext...)
+
+	encoded := base32.StdEncoding.EncodeToString(raw)
+	return []byte(encoded), nil
+}
+
+// openSeed reverses sealSeed, decrypting the seed stored in data with
+// passphrase.
+func openSeed(data []byte, passphrase string) (string, error) {
+	raw, err := base32.StdEncoding.DecodeString(string(data))
+	if err != nil {
+		return "", fmt.Errorf("not a valid atto keyfile: %w", err)
+	}
+	minLen := len(seedMagic) + 1 + kdfParamsSize + saltSize
+	if len(raw) < minLen || string(raw[:len(seedMagic)]) != seedMagic {
+		return "", fmt.Errorf("not a valid atto keyfile")
+	}
+	if raw[len(seedMagic)] != seedFormatVersion {
+		return "", fmt.Errorf("unsupported keyfile version %d", raw[len(seedMagic)])
+	}
+	raw = raw[len(seedMagic)+1:]
+	params := readKDFParams(raw[:kdfParamsSize])
+	raw = raw[kdfParamsSize:]
+	salt, raw := raw[:saltSize], raw[saltSize:]
+
+	key := argon2.IDKey([]byte(passphrase), salt, params.time, params.memory, params.threads, params.keyLen)
+	aead, err := chacha20poly1305.NewX(key)
+	if err != nil {
+		return "", fmt.Errorf("could not initialize cipher: %w", err)
+	}
+	if len(raw) < aead.NonceSize() {
+		return "", fmt.Errorf("not a valid atto keyfile")
+	}
+	nonce, ciphertext := raw[:aead.NonceSize()], raw[aead.NonceSize():]
+	seed, err := aead.Open(nil, nonce, ciphertext, nil)
+	if err != nil {
+		return "", fmt.Errorf("could not decrypt keyfile: wrong passphrase?")
+	}
+	return string(seed), nil
+}
+
+// checkPassphraseStrength rejects passphrases that zxcvbn estimates to
+// be crackable in an unthrottled online attack (score below 2),
+// printing its estimated guesses and crack-time feedback to stderr
+// either way.
+func checkPassphraseStrength(passphrase string) error {
+	result := zxcvbn.PasswordStrength(passphrase, nil)
+	guesses := math.Pow(10, result.Entropy/math.Log2(10))
+	fmt.Fprintf(os.Stderr, "Passphrase strength: score %d/4 (~%.0f guesses, crack time %s)\n", result.Score, guesses, result.CrackTimeDisplay)
+	if result.Score < minPassphraseScore {
+		return fmt.Errorf("passphrase is too weak (score %d/4, minimum %d, crack time %s); use a longer or less common passphrase, or pass --allow-weak", result.Score, minPassphraseScore, result.CrackTimeDisplay)
+	}
+	return nil
+}
+
+// readPassphrase prompts for a passphrase on the terminal without
+// echoing it. If confirmPrompt is non-empty, the passphrase must be
+// entered twice and the two entries must match.
+func readPassphrase(prompt, confirmPrompt string) (string, error) {
+	passphrase, err := readPassphraseOnce(prompt)
+	if err != nil {
+		return "", err
+	}
+	if confirmPrompt == "" {
+		return passphrase, nil
+	}
+	confirmation, err := readPassphraseOnce(confirmPrompt)
+	if err != nil {
+		return "", err
+	}
+	if passphrase != confirmation {
+		return "", fmt.Errorf("passphrases do not match")
+	}
+	return passphrase, nil
+}
+
+func readPassphraseOnce(prompt string) (string, error) {
+	fmt.Fprint(os.Stderr, prompt)
+	if term.IsTerminal(int(os.Stdin.Fd())) {
+		passphrase, err := term.ReadPassword(int(os.Stdin.Fd()))
+		fmt.Fprintln(os.Stderr)
+		if err != nil {
+			return "", fmt.Errorf("could not read passphrase: %w", err)
+		}
+		return string(passphrase), nil
+	}
+	// Stdin is not a terminal, e.g. in tests or pipelines: fall back to a
+	// plain read from the same buffered reader used for the seed, so a
+	// piped "seed\npassphrase\n" isn't swallowed by a second, independent
+	// Scanner buffering ahead on its own.
+	line, err := stdin.ReadString('\n')
+	line = strings.TrimRight(line, "\r\n")
+	if line == "" && err != nil {
+		return "", fmt.Errorf("no passphrase given")
+	}
+	return line, nil
+}
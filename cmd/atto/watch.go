@@ -0,0 +1,122 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"math/big"
+	"net/http"
+
+	"github.com/codesoap/atto"
+)
+
+// newAccountFromAddress builds an atto.Account from a public address
+// alone, without a seed or private key; only its Address field is set.
+// It is a helper of this CLI, not a constructor of the atto library.
+// The watch subcommands below use the result only for the read-only
+// RPCs FetchAccountInfo and FetchPending.
+func newAccountFromAddress(address string) atto.Account {
+	return atto.Account{Address: address}
+}
+
+func watchCommand() error {
+	address := flag.Arg(2)
+	switch flag.Arg(1) {
+	case "balance":
+		return watchBalance(address)
+	case "pending":
+		return watchPending(address)
+	case "history":
+		return watchHistory(address)
+	}
+	return fmt.Errorf("unknown watch subcommand %q", flag.Arg(1))
+}
+
+func watchBalance(address string) error {
+	account := newAccountFromAddress(address)
+	info, err := account.FetchAccountInfo(settings.NodeURL)
+	balanceRaw := "0"
+	if err == atto.ErrAccountNotFound {
+		// Account has no blocks yet; balance stays 0.
+	} else if err != nil {
+		return err
+	} else {
+		balanceRaw = info.Balance
+	}
+	balance, ok := big.NewInt(0).SetString(balanceRaw, 10)
+	if !ok {
+		return fmt.Errorf("cannot parse '%s' as an integer", balanceRaw)
+	}
+	fmt.Println(rawToXNO(balance))
+	return nil
+}
+
+func watchPending(address string) error {
+	account := newAccountFromAddress(address)
+	pendings, err := account.FetchPending(settings.NodeURL)
+	if err != nil {
+		return err
+	}
+	for _, pending := range pendings {
+		amount, ok := big.NewInt(0).SetString(pending.Amount, 10)
+		if !ok {
+			return fmt.Errorf("cannot parse '%s' as an integer", pending.Amount)
+		}
+		fmt.Printf("%s from %s\n", rawToXNO(amount), pending.Source)
+	}
+	return nil
+}
+
+func watchHistory(address string) error {
+	entries, err := fetchAccountHistory(settings.NodeURL, address, int(historyCountFlag))
+	if err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		amount := entry.Amount
+		if parsed, ok := big.NewInt(0).SetString(entry.Amount, 10); ok {
+			amount = rawToXNO(parsed)
+		}
+		fmt.Printf("%s %s %s %s\n", entry.Type, amount, entry.Account, entry.Hash)
+	}
+	return nil
+}
+
+// historyEntry is a single entry of a node's account_history response.
+type historyEntry struct {
+	Type    string `json:"type"`
+	Account string `json:"account"`
+	Amount  string `json:"amount"`
+	Hash    string `json:"hash"`
+}
+
+// fetchAccountHistory calls the read-only account_history RPC, which
+// the atto library does not wrap itself.
+func fetchAccountHistory(node, address string, count int) ([]historyEntry, error) {
+	reqBody, err := json.Marshal(map[string]any{
+		"action":  "account_history",
+		"account": address,
+		"count":   count,
+	})
+	if err != nil {
+		return nil, err
+	}
+	resp, err := http.Post(node, "application/json", bytes.NewReader(reqBody))
+	if err != nil {
+		return nil, fmt.Errorf("could not reach %s: %w", node, err)
+	}
+	defer resp.Body.Close()
+
+	var result struct {
+		History []historyEntry `json:"history"`
+		Error   string         `json:"error"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, fmt.Errorf("could not parse response from %s: %w", node, err)
+	}
+	if result.Error != "" {
+		return nil, fmt.Errorf("node error: %s", result.Error)
+	}
+	return result.History, nil
+}